@@ -0,0 +1,169 @@
+package packdir
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+// Format selects the archive container and compression backend used by PackArchive.
+type Format int
+
+const (
+	// FormatAuto is the zero value of Format. It tells PackArchive to auto-detect the format
+	// from the output file's extension instead of using a specific one; it is never passed to
+	// NewArchiver. FormatZip is deliberately non-zero so that an explicit
+	// PackOptions{Format: FormatZip} is always honored rather than being indistinguishable
+	// from "not set".
+	FormatAuto Format = iota
+	// FormatZip is the original packdir format: a standard zip archive with DEFLATE entries.
+	// It is also the format used internally by Pack.
+	FormatZip
+	// FormatTar is an uncompressed POSIX tar archive.
+	FormatTar
+	// FormatTarGz is a tar archive compressed as a whole with gzip, using klauspost/pgzip so
+	// the gzip stream is itself produced by a pool of workers.
+	FormatTarGz
+	// FormatTarZst is a tar archive compressed as a whole with zstd.
+	FormatTarZst
+)
+
+// DetectFormat guesses a Format from outfile's extension, defaulting to FormatZip when no
+// recognized archive extension is found.
+func DetectFormat(outfile string) Format {
+	switch {
+	case strings.HasSuffix(outfile, ".tar.gz"), strings.HasSuffix(outfile, ".tgz"):
+		return FormatTarGz
+	case strings.HasSuffix(outfile, ".tar.zst"), strings.HasSuffix(outfile, ".tzst"):
+		return FormatTarZst
+	case strings.HasSuffix(outfile, ".tar"):
+		return FormatTar
+	default:
+		return FormatZip
+	}
+}
+
+// Archiver abstracts over the container format written by PackArchive (and, internally, Pack),
+// so the directory walk, worker pool and error accounting don't need to know whether they are
+// writing a zip, a tar, or a compressed tar.
+type Archiver interface {
+	// CreateEntry begins a new archive entry and returns a writer for its content.
+	CreateEntry(name string, size int64, mode os.FileMode, modTime time.Time) (io.Writer, error)
+	// Close finalizes the archive, flushing any pending compression.
+	Close() error
+}
+
+// NewArchiver creates the Archiver for format, writing to w at the given compression level.
+func NewArchiver(format Format, w io.Writer, level CompressionLevel) (Archiver, error) {
+	switch format {
+	case FormatZip:
+		return newZipArchiver(w, level), nil
+	case FormatTar:
+		return newTarArchiver(w), nil
+	case FormatTarGz:
+		return newTarGzArchiver(w, level)
+	case FormatTarZst:
+		return newTarZstArchiver(w, level)
+	default:
+		return nil, fmt.Errorf("unknown archive format %d", format)
+	}
+}
+
+// zipArchiver implements Archiver on top of archive/zip, with the DEFLATE compressor
+// registered at the requested level, matching the behavior Pack always had.
+type zipArchiver struct {
+	w *zip.Writer
+}
+
+func newZipArchiver(w io.Writer, level CompressionLevel) *zipArchiver {
+	zw := zip.NewWriter(w)
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, int(level))
+	})
+	return &zipArchiver{w: zw}
+}
+
+func (a *zipArchiver) CreateEntry(name string, size int64, mode os.FileMode, modTime time.Time) (io.Writer, error) {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	header.SetMode(mode)
+	return a.w.CreateHeader(header)
+}
+
+func (a *zipArchiver) Close() error {
+	return a.w.Close()
+}
+
+// tarArchiver implements Archiver on top of archive/tar, optionally wrapping the underlying
+// writer in a compressor (pgzip for FormatTarGz, zstd for FormatTarZst).
+type tarArchiver struct {
+	w      *tar.Writer
+	closer io.Closer
+}
+
+func newTarArchiver(w io.Writer) *tarArchiver {
+	return &tarArchiver{w: tar.NewWriter(w)}
+}
+
+func newTarGzArchiver(w io.Writer, level CompressionLevel) (*tarArchiver, error) {
+	gz, err := pgzip.NewWriterLevel(w, int(level))
+	if err != nil {
+		return nil, err
+	}
+	return &tarArchiver{w: tar.NewWriter(gz), closer: gz}, nil
+}
+
+func newTarZstArchiver(w io.Writer, level CompressionLevel) (*tarArchiver, error) {
+	zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel(level)))
+	if err != nil {
+		return nil, err
+	}
+	return &tarArchiver{w: tar.NewWriter(zw), closer: zw}, nil
+}
+
+// zstdLevel maps a zip-style CompressionLevel onto the nearest zstd.EncoderLevel, since zstd
+// does not have the same 0-9 scale.
+func zstdLevel(level CompressionLevel) zstd.EncoderLevel {
+	switch {
+	case level <= 0:
+		return zstd.SpeedDefault
+	case level <= 3:
+		return zstd.SpeedFastest
+	case level <= 6:
+		return zstd.SpeedDefault
+	case level <= 8:
+		return zstd.SpeedBetterCompression
+	default:
+		return zstd.SpeedBestCompression
+	}
+}
+
+func (a *tarArchiver) CreateEntry(name string, size int64, mode os.FileMode, modTime time.Time) (io.Writer, error) {
+	header := &tar.Header{
+		Name:    name,
+		Size:    size,
+		Mode:    int64(mode.Perm()),
+		ModTime: modTime,
+	}
+	if err := a.w.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	return a.w, nil
+}
+
+func (a *tarArchiver) Close() error {
+	if err := a.w.Close(); err != nil {
+		return err
+	}
+	if a.closer != nil {
+		return a.closer.Close()
+	}
+	return nil
+}
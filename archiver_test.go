@@ -0,0 +1,81 @@
+package packdir
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPackArchiveHonorsExplicitZipFormat guards against FormatZip being indistinguishable
+// from "Format not set": PackOptions{Format: FormatZip} must always produce a zip archive,
+// even when outfile's extension would otherwise auto-detect a different format.
+func TestPackArchiveHonorsExplicitZipFormat(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outfile := filepath.Join(t.TempDir(), "forced.tar.gz")
+	if _, err := PackArchive(source, outfile, "snap", DEFAULT_COMPRESSION, 0, PackOptions{Format: FormatZip}); err != nil {
+		t.Fatalf("PackArchive: %v", err)
+	}
+
+	header := make([]byte, 2)
+	f, err := os.Open(outfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Read(header); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(header, []byte("PK")) {
+		t.Errorf("explicit FormatZip wrote magic bytes %v, want zip's \"PK\"", header)
+	}
+}
+
+// TestPackArchiveAutoDetectsFormat checks the zero-value Format, FormatAuto, still picks the
+// format up from outfile's extension.
+func TestPackArchiveAutoDetectsFormat(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outfile := filepath.Join(t.TempDir(), "snap.tar.gz")
+	if _, err := PackArchive(source, outfile, "snap", DEFAULT_COMPRESSION, 0, PackOptions{}); err != nil {
+		t.Fatalf("PackArchive: %v", err)
+	}
+
+	header := make([]byte, 2)
+	f, err := os.Open(outfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Read(header); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(header, []byte{0x1f, 0x8b}) {
+		t.Errorf("auto-detected format for .tar.gz wrote magic bytes %v, want gzip's 1f8b", header)
+	}
+}
+
+// TestPackArchiveRejectsPriorManifestForNonZipFormat guards against PackArchive silently
+// writing dedup placeholders into a tar/tar.gz/tar.zst archive that Unpack and LoadManifest
+// can never resolve back to their content, since both only read zip archives.
+func TestPackArchiveRejectsPriorManifestForNonZipFormat(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	priorManifest := Manifest{"snap/a.txt": ManifestEntry{SHA256: "deadbeef", Size: 5}}
+	outfile := filepath.Join(t.TempDir(), "snap.tar.gz")
+	if _, err := PackArchive(source, outfile, "snap", DEFAULT_COMPRESSION, 0, PackOptions{PriorManifest: priorManifest}); err == nil {
+		t.Fatal("expected an error packing a non-zip format with a non-empty PriorManifest, got nil")
+	}
+}
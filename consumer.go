@@ -0,0 +1,170 @@
+package packdir
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/docker/go-units"
+	"github.com/gosuri/uiprogress"
+)
+
+// Consumer receives progress and log events from PackParallel and Unpack, replacing the
+// flag-driven PRINT_INFO | PRINT_ERRORS | PROGRESSBAR | VERBOSE combination used by Pack. It
+// lets callers report byte-level progress (the PROGRESSBAR flag only ever ticked once per
+// file, which is inaccurate for trees with very uneven file sizes) and route log output
+// anywhere they like instead of straight to the log/fmt packages.
+type Consumer interface {
+	// OnScanProgress is called once the directory walk has finished, with the total number of
+	// files and their combined size in bytes.
+	OnScanProgress(files int64, bytes int64)
+	// OnFileStart is called before an entry begins compressing or extracting.
+	OnFileStart(name string, size int64)
+	// OnBytes is called with the number of bytes processed since the last call, for entries
+	// whose progress can be reported incrementally.
+	OnBytes(n int64)
+	// OnFileDone is called when an entry has finished; err is non-nil if it failed.
+	OnFileDone(name string, err error)
+	// Infof, Warnf and Errorf report general log messages at increasing severity.
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NoopConsumer discards all events. It is useful for callers that don't want any output, e.g.
+// library consumers that only care about the returned PackResult/UnpackResult.
+type NoopConsumer struct{}
+
+func (NoopConsumer) OnScanProgress(files int64, bytes int64)  {}
+func (NoopConsumer) OnFileStart(name string, size int64)      {}
+func (NoopConsumer) OnBytes(n int64)                          {}
+func (NoopConsumer) OnFileDone(name string, err error)        {}
+func (NoopConsumer) Infof(format string, args ...interface{}) {}
+func (NoopConsumer) Warnf(format string, args ...interface{}) {}
+func (NoopConsumer) Errorf(format string, args ...interface{}) {}
+
+// TerminalConsumer is the default Consumer, printing log messages to the console and driving a
+// uiprogress bar keyed on total bytes processed rather than file count.
+type TerminalConsumer struct {
+	Verbose bool
+
+	totalBytes int64
+	doneBytes  int64
+	bar        *uiprogress.Bar
+}
+
+// NewTerminalConsumer creates a TerminalConsumer. If verbose is true, OnFileStart also prints
+// the name of each entry as it begins processing.
+func NewTerminalConsumer(verbose bool) *TerminalConsumer {
+	return &TerminalConsumer{Verbose: verbose}
+}
+
+func (c *TerminalConsumer) OnScanProgress(files int64, bytes int64) {
+	atomic.StoreInt64(&c.totalBytes, bytes)
+	fmt.Printf("Archiving %d files with total size %d bytes.\n", files, bytes)
+	uiprogress.Start()
+	c.bar = uiprogress.AddBar(int(bytes))
+	c.bar.AppendCompleted()
+}
+
+func (c *TerminalConsumer) OnFileStart(name string, size int64) {
+	if c.Verbose {
+		fmt.Printf("Processing %s\n", name)
+	}
+}
+
+func (c *TerminalConsumer) OnBytes(n int64) {
+	done := atomic.AddInt64(&c.doneBytes, n)
+	if c.bar != nil {
+		c.bar.Set(int(done))
+	}
+}
+
+func (c *TerminalConsumer) OnFileDone(name string, err error) {
+	if err != nil {
+		c.Errorf("%s: %s", name, err)
+	}
+}
+
+func (c *TerminalConsumer) Infof(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func (c *TerminalConsumer) Warnf(format string, args ...interface{}) {
+	fmt.Printf("warning: "+format+"\n", args...)
+}
+
+func (c *TerminalConsumer) Errorf(format string, args ...interface{}) {
+	fmt.Printf("error: "+format+"\n", args...)
+}
+
+// Close stops the underlying progress bar, if one was started.
+func (c *TerminalConsumer) Close() {
+	if c.bar != nil {
+		uiprogress.Stop()
+	}
+}
+
+// flagsConsumer adapts the legacy PRINT_INFO | PRINT_ERRORS | PROGRESSBAR | VERBOSE flags to
+// the Consumer interface, so that PackParallel and Unpack only ever have to talk to a Consumer
+// internally. It is used whenever no explicit Consumer is supplied in PackOptions.
+type flagsConsumer struct {
+	flags int
+	bar   *uiprogress.Bar
+}
+
+func newFlagsConsumer(flags int) *flagsConsumer {
+	return &flagsConsumer{flags: flags}
+}
+
+func (c *flagsConsumer) OnScanProgress(files int64, bytes int64) {
+	if (c.flags & PRINT_INFO) != 0 {
+		fmt.Printf("Archiving %d files with total size %s.\n", files, units.HumanSize(float64(bytes)))
+	}
+	if (c.flags & PROGRESSBAR) != 0 {
+		uiprogress.Start()
+		c.bar = uiprogress.AddBar(int(files))
+		c.bar.AppendCompleted()
+	}
+}
+
+func (c *flagsConsumer) OnFileStart(name string, size int64) {
+	if (c.flags & VERBOSE) != 0 {
+		fmt.Printf("Compressing %s\n", name)
+	}
+}
+
+func (c *flagsConsumer) OnBytes(n int64) {}
+
+func (c *flagsConsumer) OnFileDone(name string, err error) {
+	if err != nil && (c.flags&PRINT_ERRORS) != 0 {
+		log.Printf("%s\n", err)
+	}
+	if c.bar != nil {
+		c.bar.Incr()
+	}
+}
+
+func (c *flagsConsumer) Infof(format string, args ...interface{}) {
+	if (c.flags & PRINT_INFO) != 0 {
+		fmt.Printf(format+"\n", args...)
+	}
+}
+
+func (c *flagsConsumer) Warnf(format string, args ...interface{}) {
+	if (c.flags & PRINT_ERRORS) != 0 {
+		log.Printf(format, args...)
+	}
+}
+
+func (c *flagsConsumer) Errorf(format string, args ...interface{}) {
+	if (c.flags & PRINT_ERRORS) != 0 {
+		log.Printf(format, args...)
+	}
+}
+
+func (c *flagsConsumer) stop() {
+	if c.bar != nil {
+		uiprogress.Stop()
+	}
+}
@@ -0,0 +1,68 @@
+package packdir
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+// TestFlagsConsumerErrorfRespectsPrintErrors verifies that flagsConsumer only forwards Errorf
+// (and, by the same logic, Infof/Warnf) calls when the corresponding flag bit is set, since it
+// exists specifically to preserve the old flag-gated behavior for callers that don't supply
+// their own Consumer.
+func TestFlagsConsumerErrorfRespectsPrintErrors(t *testing.T) {
+	var logged bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logged)
+
+	c := newFlagsConsumer(0)
+	// No flags set: OnFileDone must not panic or otherwise misbehave when PRINT_ERRORS is off.
+	c.OnFileDone("a.txt", errTest{"boom"})
+	c.Errorf("boom")
+	if logged.Len() != 0 {
+		t.Errorf("Errorf logged %q without PRINT_ERRORS", logged.String())
+	}
+
+	c = newFlagsConsumer(PRINT_INFO)
+	if (c.flags & PRINT_ERRORS) != 0 {
+		t.Fatal("PRINT_ERRORS unexpectedly set")
+	}
+	c.Errorf("boom")
+	if logged.Len() != 0 {
+		t.Errorf("Errorf logged %q with only PRINT_INFO set", logged.String())
+	}
+
+	c = newFlagsConsumer(PRINT_ERRORS)
+	c.Errorf("boom")
+	if logged.Len() == 0 {
+		t.Error("Errorf logged nothing with PRINT_ERRORS set")
+	}
+}
+
+// TestFlagsConsumerOnScanProgressStartsBarOnlyWithProgressbarFlag verifies that a flagsConsumer
+// does not start a uiprogress bar unless PROGRESSBAR is set, mirroring Pack's original flag
+// semantics, so callers that only pass PRINT_INFO|PRINT_ERRORS never get an unwanted bar.
+func TestFlagsConsumerOnScanProgressStartsBarOnlyWithProgressbarFlag(t *testing.T) {
+	c := newFlagsConsumer(PRINT_INFO)
+	c.OnScanProgress(3, 1024)
+	if c.bar != nil {
+		t.Error("expected no progress bar without the PROGRESSBAR flag")
+	}
+}
+
+// TestNoopConsumerDiscardsEverything verifies NoopConsumer implements Consumer and that every
+// method is safe to call with zero values and a non-nil error.
+func TestNoopConsumerDiscardsEverything(t *testing.T) {
+	var c Consumer = NoopConsumer{}
+	c.OnScanProgress(0, 0)
+	c.OnFileStart("", 0)
+	c.OnBytes(0)
+	c.OnFileDone("", errTest{"boom"})
+	c.Infof("x")
+	c.Warnf("x")
+	c.Errorf("x")
+}
+
+type errTest struct{ msg string }
+
+func (e errTest) Error() string { return e.msg }
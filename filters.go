@@ -0,0 +1,118 @@
+package packdir
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// scanResult is the outcome of getFilesAndFoldersFiltered.
+type scanResult struct {
+	files        []string
+	totalSize    int64
+	scanErrors   int64
+	skippedPaths []string
+}
+
+// getFilesAndFoldersFiltered walks root like getFilesAndFolders, but additionally honors
+// opts.Include, opts.Exclude, opts.FollowSymlinks, opts.MaxFileSize and opts.MaxTotalSize.
+// Include/Exclude patterns are doublestar globs matched against each entry's slash-separated
+// path relative to root; a directory matching Exclude is pruned from the walk entirely.
+// FollowSymlinks only decides whether symlinked files are included and their target content
+// copied - traversal never descends into symlinked directories, since filepath.Walk lstats
+// entries and doing so manually would risk following a symlink cycle.
+func getFilesAndFoldersFiltered(root string, flags int, opts PackOptions) scanResult {
+	var res scanResult
+	var runningTotal int64
+
+	visit := func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if (flags & PRINT_ERRORS) != 0 {
+				log.Printf("%s\n", err)
+			}
+			res.scanErrors += 1
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			rel = p
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if rel != "." && matchesAny(opts.Exclude, rel) {
+				res.skippedPaths = append(res.skippedPaths, p)
+				return filepath.SkipDir
+			}
+			res.files = append(res.files, p)
+			return nil
+		}
+
+		isSymlink := info.Mode()&os.ModeSymlink != 0
+		if isSymlink && !opts.FollowSymlinks {
+			res.skippedPaths = append(res.skippedPaths, p)
+			return nil
+		}
+
+		if len(opts.Include) > 0 && !matchesAny(opts.Include, rel) {
+			res.skippedPaths = append(res.skippedPaths, p)
+			return nil
+		}
+		if matchesAny(opts.Exclude, rel) {
+			res.skippedPaths = append(res.skippedPaths, p)
+			return nil
+		}
+
+		// info is Lstat-based, so for a followed symlink info.Size() is just the length of
+		// the link target string, not the size of what will actually be read and archived.
+		// Stat through the link to get the real size before applying the size caps.
+		size := info.Size()
+		if isSymlink {
+			target, statErr := os.Stat(p)
+			if statErr != nil {
+				if (flags & PRINT_ERRORS) != 0 {
+					log.Printf("%s\n", statErr)
+				}
+				res.scanErrors += 1
+				return nil
+			}
+			size = target.Size()
+		}
+		if opts.MaxFileSize > 0 && size > opts.MaxFileSize {
+			res.skippedPaths = append(res.skippedPaths, p)
+			return nil
+		}
+		if opts.MaxTotalSize > 0 && runningTotal+size > opts.MaxTotalSize {
+			res.skippedPaths = append(res.skippedPaths, p)
+			return nil
+		}
+
+		runningTotal += size
+		res.totalSize += size
+		res.files = append(res.files, p)
+		return nil
+	}
+
+	if (flags & VERBOSE) != 0 {
+		fmt.Printf("Scanning directory... ")
+	}
+	filepath.Walk(root, visit)
+	if (flags & VERBOSE) != 0 {
+		fmt.Printf("done.\n")
+	}
+	return res
+}
+
+// matchesAny reports whether rel matches any of the doublestar glob patterns.
+func matchesAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, err := doublestar.Match(pattern, rel); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package packdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGetFilesAndFoldersFilteredSymlinkSizeRespectsMaxFileSize guards against MaxFileSize
+// being bypassed by a followed symlink: filepath.Walk reports a symlink's Lstat size, which is
+// just the length of the link target string, not the size of the file it points at.
+func TestGetFilesAndFoldersFilteredSymlinkSizeRespectsMaxFileSize(t *testing.T) {
+	root := t.TempDir()
+
+	big := filepath.Join(root, "big.bin")
+	if err := os.WriteFile(big, make([]byte, 2_000_000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "link.bin")
+	if err := os.Symlink(big, link); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := PackOptions{FollowSymlinks: true, MaxFileSize: 1000}
+	scan := getFilesAndFoldersFiltered(root, 0, opts)
+
+	for _, f := range scan.files {
+		if f == link {
+			t.Fatalf("symlink to a 2MB file was not skipped despite MaxFileSize: 1000; files=%v", scan.files)
+		}
+	}
+	found := false
+	for _, p := range scan.skippedPaths {
+		if p == link {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("symlink %s not recorded in skippedPaths: %v", link, scan.skippedPaths)
+	}
+}
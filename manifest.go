@@ -0,0 +1,96 @@
+package packdir
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// manifestEntryName is the archive path under which the manifest is embedded by PackArchive.
+const manifestEntryName = "manifest.json"
+
+// ManifestEntry records the content hash, size and modification time of one file in a
+// snapshot, keyed by its archive path in a Manifest. Placeholder is true when the entry's
+// content was omitted from the archive because its SHA256 matched some other file's - either
+// another entry earlier in the same snapshot, or an entry in PackOptions.PriorManifest,
+// regardless of whether either lived at the same archive path. Such entries are stored as
+// zero-byte files; DedupOf names the archive path that actually holds the content, which
+// Unpack resolves first against the current archive and then, if not found there, against the
+// prior archive.
+type ManifestEntry struct {
+	SHA256      string    `json:"sha256"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	Placeholder bool      `json:"placeholder,omitempty"`
+	DedupOf     string    `json:"dedup_of,omitempty"`
+}
+
+// Manifest maps archive paths to ManifestEntry. PackArchive embeds one as manifest.json in
+// every archive it writes, which doubles as an integrity/verification artifact and, when
+// passed back in as PackOptions.PriorManifest for a later snapshot, enables content-addressable
+// deduplication of unchanged files by SHA256, independent of path.
+type Manifest map[string]ManifestEntry
+
+// LoadManifest reads the embedded manifest.json from a zip archive previously written by
+// PackArchive, for use as PackOptions.PriorManifest in a later incremental snapshot. Archives
+// written by Pack or PackParallel have no manifest.json, and LoadManifest returns an error for them.
+// zipPath must be a zip archive: PackArchive only ever populates PriorManifest-compatible
+// placeholders for FormatZip, since Unpack cannot read tar, tar.gz or tar.zst archives.
+func LoadManifest(zipPath string) (Manifest, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	m, err := decodeManifest(r.File)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, fmt.Errorf("%s: no %s entry", zipPath, manifestEntryName)
+	}
+	return m, nil
+}
+
+// decodeManifest finds and decodes the manifest.json entry among files, returning (nil, nil)
+// if there is none.
+func decodeManifest(files []*zip.File) (Manifest, error) {
+	for _, f := range files {
+		if f.Name != manifestEntryName {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+
+		var m Manifest
+		if err := json.NewDecoder(rc).Decode(&m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, nil
+}
+
+// sha256File returns the lowercase hex-encoded SHA256 of the file at path.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
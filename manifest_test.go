@@ -0,0 +1,115 @@
+package packdir
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPackArchiveDedupsIdenticalContentAcrossPaths verifies that two different files with
+// identical content in the same snapshot are deduplicated against each other by SHA256, not
+// just files unchanged at the same archive path across snapshots.
+func TestPackArchiveDedupsIdenticalContentAcrossPaths(t *testing.T) {
+	source := t.TempDir()
+	content := []byte("identical content in both files\n")
+	if err := os.WriteFile(filepath.Join(source, "a.txt"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "b.txt"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outfile := filepath.Join(t.TempDir(), "snap.zip")
+	if _, err := PackArchive(source, outfile, "snap", DEFAULT_COMPRESSION, 0, PackOptions{}); err != nil {
+		t.Fatalf("PackArchive: %v", err)
+	}
+
+	r, err := zip.OpenReader(outfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	manifest, err := decodeManifest(r.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, ok := manifest["snap/a.txt"]
+	if !ok {
+		t.Fatal("manifest missing snap/a.txt")
+	}
+	b, ok := manifest["snap/b.txt"]
+	if !ok {
+		t.Fatal("manifest missing snap/b.txt")
+	}
+
+	if a.Placeholder == b.Placeholder {
+		t.Fatalf("expected exactly one of a.txt/b.txt to be a placeholder deduplicated against the other, got a.Placeholder=%v b.Placeholder=%v", a.Placeholder, b.Placeholder)
+	}
+	placeholder := a
+	if b.Placeholder {
+		placeholder = b
+	}
+	if placeholder.DedupOf != "snap/a.txt" && placeholder.DedupOf != "snap/b.txt" {
+		t.Fatalf("placeholder DedupOf %q does not name either entry", placeholder.DedupOf)
+	}
+}
+
+// TestPackArchiveIncrementalSnapshotRoundTrip packs two snapshots of a source directory where
+// one file is unchanged and one is modified, wires the first snapshot through as PriorManifest
+// and PriorArchive, and verifies that Unpack restores the unchanged file's real content rather
+// than the empty placeholder written for it in the second snapshot's archive.
+func TestPackArchiveIncrementalSnapshotRoundTrip(t *testing.T) {
+	source := t.TempDir()
+	aPath := filepath.Join(source, "a.txt")
+	bPath := filepath.Join(source, "b.txt")
+	if err := os.WriteFile(aPath, []byte("unchanged across both snapshots\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bPath, []byte("version one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archiveDir := t.TempDir()
+	snap1 := filepath.Join(archiveDir, "snap1.zip")
+	if _, err := PackArchive(source, snap1, "snap", DEFAULT_COMPRESSION, 0, PackOptions{}); err != nil {
+		t.Fatalf("PackArchive snap1: %v", err)
+	}
+
+	priorManifest, err := LoadManifest(snap1)
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+
+	if err := os.WriteFile(bPath, []byte("version two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap2 := filepath.Join(archiveDir, "snap2.zip")
+	if _, err := PackArchive(source, snap2, "snap", DEFAULT_COMPRESSION, 0, PackOptions{PriorManifest: priorManifest}); err != nil {
+		t.Fatalf("PackArchive snap2: %v", err)
+	}
+
+	destDir := t.TempDir()
+	if _, err := Unpack(snap2, destDir, UnpackOptions{TargetBaseDir: "snap", PriorArchive: snap1}); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+
+	gotA, err := os.ReadFile(filepath.Join(destDir, "a.txt"))
+	if err != nil {
+		t.Fatalf("reading extracted a.txt: %v", err)
+	}
+	if string(gotA) != "unchanged across both snapshots\n" {
+		t.Errorf("a.txt round-tripped as %q, want its unchanged content", gotA)
+	}
+
+	gotB, err := os.ReadFile(filepath.Join(destDir, "b.txt"))
+	if err != nil {
+		t.Fatalf("reading extracted b.txt: %v", err)
+	}
+	if string(gotB) != "version two\n" {
+		t.Errorf("b.txt round-tripped as %q, want its updated content", gotB)
+	}
+}
@@ -0,0 +1,223 @@
+package packdir
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// PackArchive packs a directory like Pack, but selects its container format from opts.Format.
+// opts.Format's zero value, FormatAuto, auto-detects the format from outfile's extension;
+// any other value, including FormatZip, is honored as-is regardless of outfile's extension.
+// Every format is routed through the Archiver interface, so tar, tar.gz (parallel gzip via
+// klauspost/pgzip) and tar.zst archives get the same filtered walk, Consumer wiring and
+// PackResult accounting as zip archives.
+//
+// Deduplication (opts.PriorManifest, and matching content within the same snapshot) is only
+// performed for FormatZip: Unpack and LoadManifest only read zip archives, so a zero-byte
+// placeholder entry written into a tar, tar.gz or tar.zst archive could never be resolved back
+// to its content, by this library or any other tool. PackArchive rejects a non-empty
+// opts.PriorManifest for any other format, and silently stores full content instead of
+// placeholders for intra-snapshot duplicates.
+func PackArchive(source string, outfile string, targetBaseDir string,
+	level CompressionLevel, flags int, opts PackOptions) (*PackResult, error) {
+
+	if level < -2 || level > 9 {
+		if (flags & PRINT_ERRORS) != 0 {
+			log.Printf("Unsupported compression level %d, using level 2 instead.\n", level)
+		}
+		level = 2
+	}
+
+	opts = opts.withDefaults()
+	format := opts.Format
+	if format == FormatAuto {
+		format = DetectFormat(outfile)
+	}
+
+	result := new(PackResult)
+
+	consumer := opts.Consumer
+	fc, usingFlags := consumer.(*flagsConsumer)
+	if consumer == nil {
+		fc = newFlagsConsumer(flags)
+		consumer = fc
+		usingFlags = true
+	}
+
+	dedupEnabled := format == FormatZip
+	if !dedupEnabled && len(opts.PriorManifest) > 0 {
+		err := fmt.Errorf("packdir: opts.PriorManifest is only supported for FormatZip, not format %d", format)
+		consumer.Errorf("%s", err)
+		if usingFlags {
+			fc.stop()
+		}
+		result.ArchiveErrNum += 1
+		return result, err
+	}
+
+	source = path.Clean(source)
+	if targetBaseDir == "" {
+		targetBaseDir = path.Base(source)
+	}
+	if targetBaseDir == "." {
+		targetBaseDir = "snapshot"
+	}
+	if targetBaseDir[len(targetBaseDir)-1:] == "/" {
+		targetBaseDir = targetBaseDir[:len(targetBaseDir)-1]
+	}
+
+	scan := getFilesAndFoldersFiltered(source, flags, opts)
+	files := scan.files
+	result.ScanErrNum = scan.scanErrors
+	result.FileNum = scan.totalSize
+	result.SkippedNum = int64(len(scan.skippedPaths))
+	result.SkippedPaths = scan.skippedPaths
+	consumer.OnScanProgress(int64(len(files)), scan.totalSize)
+
+	outFile, err := os.Create(outfile)
+	if err != nil {
+		consumer.Errorf("%s", err)
+		result.ArchiveErrNum += 1
+		return result, err
+	}
+	defer outFile.Close()
+
+	archiver, err := NewArchiver(format, outFile, level)
+	if err != nil {
+		consumer.Errorf("%s", err)
+		result.ArchiveErrNum += 1
+		return result, err
+	}
+
+	buff := make([]byte, 65536)
+	manifest := make(Manifest)
+	var errNum2 int64
+
+	// priorByHash indexes opts.PriorManifest by content hash rather than path, so a file that
+	// was merely renamed (or that duplicates some other file's content) between snapshots is
+	// still recognized as unchanged.
+	priorByHash := make(map[string]ManifestEntry, len(opts.PriorManifest))
+	for priorPath, entry := range opts.PriorManifest {
+		if entry.Placeholder {
+			continue
+		}
+		if _, ok := priorByHash[entry.SHA256]; !ok {
+			priorByHash[entry.SHA256] = ManifestEntry{SHA256: entry.SHA256, Size: entry.Size, ModTime: entry.ModTime, DedupOf: priorPath}
+		}
+	}
+
+	// hashSource tracks, for each content hash already seen in this snapshot, the archive path
+	// that holds its real (non-placeholder) bytes - either a file packed earlier in this same
+	// run, or (if this is the first occurrence and it matched priorByHash) a path in the prior
+	// archive. Every later placeholder for that hash points directly at this one source, so
+	// Unpack never has to follow more than one hop.
+	hashSource := make(map[string]string)
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			consumer.Errorf("%s", err)
+			errNum2 += 1
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		consumer.OnFileStart(file, info.Size())
+		name := zipEntryName(file, source, targetBaseDir)
+
+		entryErr := func() error {
+			hash, err := sha256File(file)
+			if err != nil {
+				return err
+			}
+
+			if dedupEnabled {
+				if src, ok := hashSource[hash]; ok {
+					if _, err := archiver.CreateEntry(name, 0, info.Mode(), info.ModTime()); err != nil {
+						return err
+					}
+					manifest[name] = ManifestEntry{SHA256: hash, Size: info.Size(), ModTime: info.ModTime(), Placeholder: true, DedupOf: src}
+					return nil
+				}
+
+				if prior, ok := priorByHash[hash]; ok && prior.Size == info.Size() {
+					if _, err := archiver.CreateEntry(name, 0, info.Mode(), info.ModTime()); err != nil {
+						return err
+					}
+					manifest[name] = ManifestEntry{SHA256: hash, Size: info.Size(), ModTime: info.ModTime(), Placeholder: true, DedupOf: prior.DedupOf}
+					hashSource[hash] = prior.DedupOf
+					return nil
+				}
+			}
+
+			in, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			target, err := archiver.CreateEntry(name, info.Size(), info.Mode(), info.ModTime())
+			if err != nil {
+				return err
+			}
+			if _, err := io.CopyBuffer(target, in, buff); err != nil {
+				return err
+			}
+			manifest[name] = ManifestEntry{SHA256: hash, Size: info.Size(), ModTime: info.ModTime()}
+			hashSource[hash] = name
+			return nil
+		}()
+
+		if entryErr != nil {
+			consumer.Errorf("%s", entryErr)
+			errNum2 += 1
+		}
+		consumer.OnBytes(info.Size())
+		consumer.OnFileDone(file, entryErr)
+	}
+	result.ArchiveErrNum = errNum2
+
+	if manifestErr := writeManifest(archiver, manifest); manifestErr != nil {
+		consumer.Errorf("%s", manifestErr)
+		result.ArchiveErrNum += 1
+	}
+
+	if err := archiver.Close(); err != nil {
+		consumer.Errorf("%s", err)
+		result.ArchiveErrNum += 1
+	}
+
+	if usingFlags {
+		fc.stop()
+		if (flags & PRINT_INFO) != 0 {
+			if result.ArchiveErrNum > 0 {
+				fmt.Printf("Done, %d errors during archiving.\n", result.ArchiveErrNum)
+			} else {
+				fmt.Printf("Done.\n")
+			}
+		}
+	}
+	return result, nil
+}
+
+// writeManifest embeds manifest as a manifest.json entry in the archive, so the snapshot can
+// later be used as PackOptions.PriorManifest (via LoadManifest) or checked for integrity.
+func writeManifest(archiver Archiver, manifest Manifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	target, err := archiver.CreateEntry(manifestEntryName, int64(len(data)), 0644, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = target.Write(data)
+	return err
+}
@@ -4,8 +4,6 @@
 package packdir
 
 import (
-	"archive/zip"
-	"compress/flate"
 	"fmt"
 	"io"
 	"log"
@@ -49,11 +47,16 @@ func getFilesAndFolders(path string, flags int) ([]string, int64, int64) {
 	return results, size, errors
 }
 
-func addFile(w *zip.Writer, file string, sourceBase string, targetBase string, buffer *[]byte, flags int) error {
-	if file[0:] == "/" {
-		file = file[1:]
-	}
-	toAdd := targetBase + "/" + strings.TrimLeft(file, sourceBase)
+// zipEntryName computes the path under which file is stored in the archive, stripping the
+// sourceBase prefix (and any leading slash left behind) and replacing it with targetBase.
+func zipEntryName(file string, sourceBase string, targetBase string) string {
+	rel := strings.TrimPrefix(file, sourceBase)
+	rel = strings.TrimPrefix(rel, "/")
+	return targetBase + "/" + rel
+}
+
+func addFile(a Archiver, file string, sourceBase string, targetBase string, buffer *[]byte, flags int) error {
+	toAdd := zipEntryName(file, sourceBase, targetBase)
 
 	if (flags & VERBOSE) != 0 {
 		fmt.Printf("Compressing %s\n", file)
@@ -69,7 +72,7 @@ func addFile(w *zip.Writer, file string, sourceBase string, targetBase string, b
 		return err
 	}
 
-	target, err := w.Create(toAdd)
+	target, err := a.CreateEntry(toAdd, stat.Size(), stat.Mode(), stat.ModTime())
 	if err != nil {
 		return err
 	}
@@ -82,11 +85,15 @@ func addFile(w *zip.Writer, file string, sourceBase string, targetBase string, b
 }
 
 // PackResult holds results of a packing operation. ScanErrNum represents the number of errors during file scanning,
-// whereas ArchiveErrNum is the number of errors during archiving.
+// whereas ArchiveErrNum is the number of errors during archiving. SkippedNum and SkippedPaths are only populated by
+// PackParallel and PackArchive, whose PackOptions can filter out files via Include, Exclude, FollowSymlinks,
+// MaxFileSize and MaxTotalSize.
 type PackResult struct {
 	FileNum       int64
 	ScanErrNum    int64
 	ArchiveErrNum int64
+	SkippedNum    int64
+	SkippedPaths  []string
 }
 
 // Flags to control the display and logging of events at the console.
@@ -175,14 +182,11 @@ func Pack(source string, outfile string, targetBaseDir string,
 	}
 	defer outFile.Close()
 
-	// create the archive
-	writer := zip.NewWriter(outFile)
+	// create the archive; Pack always uses the zip backend, selected via the Archiver
+	// abstraction shared with PackArchive.
+	writer := newZipArchiver(outFile, level)
 	buff := make([]byte, 65536)
 
-	writer.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
-		return flate.NewWriter(out, int(level))
-	})
-
 	var bar *uiprogress.Bar
 
 	if (flags & PROGRESSBAR) != 0 {
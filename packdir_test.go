@@ -0,0 +1,69 @@
+package packdir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZipEntryNameNestedSource(t *testing.T) {
+	cases := []struct {
+		file       string
+		sourceBase string
+		targetBase string
+		want       string
+	}{
+		{"nested/project/moduledir/mod.go", "nested/project/moduledir", "snap", "snap/mod.go"},
+		{"nested/project/moduledir/driver.c", "nested/project/moduledir", "snap", "snap/driver.c"},
+		{"/nested/project/moduledir/mod.go", "/nested/project/moduledir", "snap", "snap/mod.go"},
+	}
+	for _, c := range cases {
+		got := zipEntryName(c.file, c.sourceBase, c.targetBase)
+		if got != c.want {
+			t.Errorf("zipEntryName(%q, %q, %q) = %q, want %q", c.file, c.sourceBase, c.targetBase, got, c.want)
+		}
+	}
+}
+
+// TestPackUnpackRoundTripNestedSource packs a source directory whose name shares many
+// characters with its own files (the condition under which strings.TrimLeft silently
+// truncated entry names instead of stripping the source prefix) and verifies every file's
+// name and content survive a Pack/Unpack round trip unchanged.
+func TestPackUnpackRoundTripNestedSource(t *testing.T) {
+	srcRoot := t.TempDir()
+	source := filepath.Join(srcRoot, "nested", "project", "moduledir")
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"mod.go":   "package moduledir\n",
+		"driver.c": "int main(void) { return 0; }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(source, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive := filepath.Join(t.TempDir(), "snap.zip")
+	if _, err := Pack(source, archive, "snap", DEFAULT_COMPRESSION, 0); err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	destDir := t.TempDir()
+	if _, err := Unpack(archive, destDir, UnpackOptions{TargetBaseDir: "snap"}); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(destDir, name))
+		if err != nil {
+			t.Errorf("reading extracted %s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s round-tripped as %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package packdir
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path"
+	"runtime"
+	"sync"
+)
+
+// PackOptions configures the behavior of PackParallel. The zero value is valid: WorkerCount
+// defaults to runtime.NumCPU(), BlockSize defaults to 1 MB and MinParallelSize defaults to 6 MB.
+type PackOptions struct {
+	// WorkerCount is the number of goroutines used to compress files concurrently.
+	WorkerCount int
+	// BlockSize is the size of the independent DEFLATE blocks that files at or above
+	// MinParallelSize are split into.
+	BlockSize int64
+	// MinParallelSize is the file size threshold at or above which a file is split into blocks and
+	// compressed in parallel instead of as a single DEFLATE stream.
+	MinParallelSize int64
+	// Consumer, if non-nil, receives progress and log events instead of the flags passed to
+	// PackParallel or PackArchive: PRINT_INFO and PROGRESSBAR then have no effect. PRINT_ERRORS
+	// and VERBOSE still control the messages printed while scanning the source directory and
+	// the warning about an unsupported compression level.
+	Consumer Consumer
+	// Format selects the archive container used by PackArchive. It is ignored by PackParallel,
+	// which always writes zip with raw DEFLATE blocks. The zero value, FormatAuto, triggers
+	// auto-detection from the output file's extension in PackArchive.
+	Format Format
+	// PriorManifest, if non-nil, is the Manifest (typically loaded with LoadManifest) of a
+	// previous snapshot of the same source tree. PackArchive stores any file whose SHA256 and
+	// size match an entry in PriorManifest as a zero-byte placeholder rather than recompressing
+	// and rewriting its content, and marks it as such in the embedded manifest.json.
+	PriorManifest Manifest
+	// Include, if non-empty, restricts archiving to files whose path relative to source
+	// matches at least one doublestar glob pattern (e.g. "**/*.go").
+	Include []string
+	// Exclude skips files, and prunes whole directories, whose path relative to source matches
+	// any doublestar glob pattern (e.g. "**/node_modules/**").
+	Exclude []string
+	// FollowSymlinks includes symlinked files (copying the target's content) instead of
+	// skipping them. It does not make the walk descend into symlinked directories.
+	FollowSymlinks bool
+	// MaxFileSize, if positive, skips any individual file larger than this many bytes.
+	MaxFileSize int64
+	// MaxTotalSize, if positive, stops adding files once their cumulative size would exceed
+	// this many bytes; later, smaller files that still fit are still added.
+	MaxTotalSize int64
+}
+
+const (
+	defaultBlockSize       = 1 << 20  // 1 MB
+	defaultMinParallelSize = 6 << 20  // 6 MB
+	dictWindowSize         = 32 << 10 // 32 KB, the maximum DEFLATE back-reference window
+)
+
+func (opts PackOptions) withDefaults() PackOptions {
+	if opts.WorkerCount <= 0 {
+		opts.WorkerCount = runtime.NumCPU()
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = defaultBlockSize
+	}
+	if opts.MinParallelSize <= 0 {
+		opts.MinParallelSize = defaultMinParallelSize
+	}
+	return opts
+}
+
+// parallelEntry holds the outcome of compressing one file for PackParallel.
+type parallelEntry struct {
+	data []byte
+	crc  uint32
+	size int64
+	skip bool
+	err  error
+}
+
+// PackParallel packs a directory like Pack, but compresses up to opts.WorkerCount files
+// concurrently. Files at or above opts.MinParallelSize are additionally split into
+// opts.BlockSize independent DEFLATE blocks that are themselves compressed in parallel, using
+// the trailing 32 KB of each block as a shared dictionary for the next (via
+// flate.NewWriterDict) and concatenating the results so the archive still holds a single
+// standards-compliant DEFLATE payload per file. CRC32 is computed over the original,
+// uncompressed bytes. Entries are written to the output zip in the same order as the scanned
+// file list, regardless of which worker finished compressing them first.
+func PackParallel(source string, outfile string, targetBaseDir string,
+	level CompressionLevel, flags int, opts PackOptions) (*PackResult, error) {
+
+	if level < -2 || level > 9 {
+		if (flags & PRINT_ERRORS) != 0 {
+			log.Printf("Unsupported compression level %d, using level 2 instead.\n", level)
+		}
+		level = 2
+	}
+
+	opts = opts.withDefaults()
+	result := new(PackResult)
+
+	consumer := opts.Consumer
+	fc, usingFlags := consumer.(*flagsConsumer)
+	if consumer == nil {
+		fc = newFlagsConsumer(flags)
+		consumer = fc
+		usingFlags = true
+	}
+
+	source = path.Clean(source)
+
+	if targetBaseDir == "" {
+		targetBaseDir = path.Base(source)
+	}
+	if targetBaseDir == "." {
+		targetBaseDir = "snapshot"
+	}
+	if targetBaseDir[len(targetBaseDir)-1:] == "/" {
+		targetBaseDir = targetBaseDir[:len(targetBaseDir)-1]
+	}
+
+	scan := getFilesAndFoldersFiltered(source, flags, opts)
+	files := scan.files
+	result.ScanErrNum = scan.scanErrors
+	result.FileNum = scan.totalSize
+	result.SkippedNum = int64(len(scan.skippedPaths))
+	result.SkippedPaths = scan.skippedPaths
+
+	consumer.OnScanProgress(int64(len(files)), scan.totalSize)
+
+	outFile, err := os.Create(outfile)
+	if err != nil {
+		consumer.Errorf("%s", err)
+		result.ArchiveErrNum += 1
+		return result, err
+	}
+	defer outFile.Close()
+
+	writer := zip.NewWriter(outFile)
+
+	entries := make([]parallelEntry, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for n := 0; n < opts.WorkerCount; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				consumer.OnFileStart(files[i], 0)
+				data, crc, size, skip, err := compressEntry(files[i], level, opts)
+				entries[i] = parallelEntry{data: data, crc: crc, size: size, skip: skip, err: err}
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var errNum2 int64
+	for i, file := range files {
+		e := entries[i]
+		if e.err != nil {
+			consumer.Errorf("%s", e.err)
+			consumer.OnFileDone(file, e.err)
+			errNum2 += 1
+			continue
+		}
+		if e.skip {
+			continue
+		}
+
+		header := &zip.FileHeader{
+			Name:               zipEntryName(file, source, targetBaseDir),
+			Method:             zip.Deflate,
+			UncompressedSize64: uint64(e.size),
+			CompressedSize64:   uint64(len(e.data)),
+			CRC32:              e.crc,
+		}
+		target, err := writer.CreateRaw(header)
+		if err == nil {
+			_, err = target.Write(e.data)
+		}
+		if err != nil {
+			consumer.Errorf("%s", err)
+			errNum2 += 1
+		}
+		consumer.OnBytes(e.size)
+		consumer.OnFileDone(file, err)
+	}
+	result.ArchiveErrNum = errNum2
+
+	err = writer.Close()
+	if err != nil {
+		consumer.Errorf("%s", err)
+		result.ArchiveErrNum += 1
+	}
+	if usingFlags {
+		fc.stop()
+		if (flags & PRINT_INFO) != 0 {
+			if result.ArchiveErrNum > 0 {
+				fmt.Printf("Done, %d errors during archiving.\n", result.ArchiveErrNum)
+			} else {
+				fmt.Printf("Done.\n")
+			}
+		}
+	}
+	return result, nil
+}
+
+// compressEntry reads file and DEFLATE-compresses it, splitting it into parallel blocks when
+// it is at or above opts.MinParallelSize. It returns the compressed bytes, the CRC32 of the
+// uncompressed content, the uncompressed size, and whether the entry should be skipped because
+// it is a directory.
+func compressEntry(file string, level CompressionLevel, opts PackOptions) (data []byte, crc uint32, size int64, skip bool, err error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+	if info.IsDir() {
+		return nil, 0, 0, true, nil
+	}
+
+	source, err := os.Open(file)
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+	defer source.Close()
+
+	size = info.Size()
+	hasher := crc32.NewIEEE()
+	reader := io.TeeReader(source, hasher)
+
+	if size < opts.MinParallelSize {
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, int(level))
+		if err != nil {
+			return nil, 0, 0, false, err
+		}
+		if _, err := io.Copy(fw, reader); err != nil {
+			return nil, 0, 0, false, err
+		}
+		if err := fw.Close(); err != nil {
+			return nil, 0, 0, false, err
+		}
+		return buf.Bytes(), hasher.Sum32(), size, false, nil
+	}
+
+	data, err = compressBlocksParallel(reader, level, opts)
+	return data, hasher.Sum32(), size, false, err
+}
+
+// deflateBlock is one independently-compressible chunk of a large file, together with the
+// shared-window dictionary carried over from the previous block.
+type deflateBlock struct {
+	data []byte
+	dict []byte
+}
+
+// compressBlocksParallel splits r into opts.BlockSize chunks, compresses them concurrently
+// across opts.WorkerCount workers and concatenates the results into a single DEFLATE stream.
+// Each block (other than the first) is compressed with flate.NewWriterDict using the trailing
+// dictWindowSize bytes of the previous block as its dictionary, and only the final block's
+// writer is Close()d (the others are Flush()ed) so the concatenation remains a single stream
+// with exactly one end-of-stream marker.
+func compressBlocksParallel(r io.Reader, level CompressionLevel, opts PackOptions) ([]byte, error) {
+	var blocks []deflateBlock
+	var prevTail []byte
+	chunk := make([]byte, opts.BlockSize)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, chunk[:n])
+			blocks = append(blocks, deflateBlock{data: data, dict: prevTail})
+			prevTail = trailingWindow(data)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, deflateBlock{})
+	}
+
+	results := make([][]byte, len(blocks))
+	errs := make([]error, len(blocks))
+	sem := make(chan struct{}, opts.WorkerCount)
+	var wg sync.WaitGroup
+
+	for i, b := range blocks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, b deflateBlock) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var out bytes.Buffer
+			var fw *flate.Writer
+			var err error
+			if b.dict != nil {
+				fw, err = flate.NewWriterDict(&out, int(level), b.dict)
+			} else {
+				fw, err = flate.NewWriter(&out, int(level))
+			}
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if _, err := fw.Write(b.data); err != nil {
+				errs[i] = err
+				return
+			}
+			if i == len(blocks)-1 {
+				err = fw.Close()
+			} else {
+				err = fw.Flush()
+			}
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i] = out.Bytes()
+		}(i, b)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var final bytes.Buffer
+	for _, b := range results {
+		final.Write(b)
+	}
+	return final.Bytes(), nil
+}
+
+// trailingWindow returns a copy of the final dictWindowSize bytes of data (or all of it, if
+// shorter), for use as the flate dictionary of the following block.
+func trailingWindow(data []byte) []byte {
+	if int64(len(data)) <= dictWindowSize {
+		out := make([]byte, len(data))
+		copy(out, data)
+		return out
+	}
+	out := make([]byte, dictWindowSize)
+	copy(out, data[len(data)-dictWindowSize:])
+	return out
+}
@@ -0,0 +1,73 @@
+package packdir
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPackParallelBlockSplitRoundTrip forces a file through the multi-block, dictionary
+// carry-over DEFLATE path (compressBlocksParallel) by setting BlockSize/MinParallelSize well
+// below the file's size, then verifies a standard archive/zip reader can decompress the result
+// back to the exact original bytes. The content varies from block to block (rather than
+// repeating a single byte) so a broken dictionary boundary would actually corrupt the output.
+func TestPackParallelBlockSplitRoundTrip(t *testing.T) {
+	const blockSize = 4096
+	const numBlocks = 6
+
+	var buf bytes.Buffer
+	for i := 0; i < numBlocks+1; i++ {
+		line := fmt.Sprintf("block %d: the quick brown fox jumps over the lazy dog. ", i)
+		for buf.Len() < (i+1)*blockSize {
+			buf.WriteString(line)
+		}
+	}
+	want := buf.Bytes()[:numBlocks*blockSize+123] // span a partial trailing block too
+
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "big.bin"), want, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outfile := filepath.Join(t.TempDir(), "snap.zip")
+	opts := PackOptions{BlockSize: blockSize, MinParallelSize: blockSize, WorkerCount: 4}
+	if _, err := PackParallel(source, outfile, "snap", DEFAULT_COMPRESSION, 0, opts); err != nil {
+		t.Fatalf("PackParallel: %v", err)
+	}
+
+	r, err := zip.OpenReader(outfile)
+	if err != nil {
+		t.Fatalf("opening result as a standard zip archive: %v", err)
+	}
+	defer r.Close()
+
+	var entry *zip.File
+	for _, f := range r.File {
+		if f.Name == "snap/big.bin" {
+			entry = f
+			break
+		}
+	}
+	if entry == nil {
+		t.Fatal("snap/big.bin not found in archive")
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("block-split round trip produced %d bytes, want %d bytes matching the original (first mismatch corrupts the dictionary-carryover path)", len(got), len(want))
+	}
+}
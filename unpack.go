@@ -0,0 +1,245 @@
+package packdir
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UnpackProgressFunc is called once per archive entry as Unpack processes it. err is non-nil
+// when the entry could not be extracted; the entry is still counted in UnpackResult either way.
+type UnpackProgressFunc func(name string, size int64, err error)
+
+// UnpackOptions configures Unpack and UnpackReader.
+type UnpackOptions struct {
+	// TargetBaseDir, if non-empty, is stripped from the front of every archive entry path
+	// before it is extracted, mirroring the targetBaseDir prefix added by Pack.
+	TargetBaseDir string
+	// Overwrite allows extraction to replace files that already exist in destDir. If false,
+	// an existing file is reported as a per-entry error and left untouched.
+	Overwrite bool
+	// Progress, if non-nil, is called after each entry is processed (successfully or not).
+	Progress UnpackProgressFunc
+	// Consumer, if non-nil, receives the same start/bytes/done events as PackParallel in
+	// addition to Progress, so a single TerminalConsumer can drive both directions.
+	Consumer Consumer
+	// PriorArchive, if non-empty, is the path to the prior snapshot archive that zero-byte
+	// placeholder entries (written by PackArchive when deduplicating against a
+	// PackOptions.PriorManifest) are resolved against, using the embedded manifest.json to
+	// find the matching entry by name.
+	PriorArchive string
+}
+
+// UnpackResult holds the outcome of an Unpack or UnpackReader call.
+type UnpackResult struct {
+	ExtractedNum int64
+	SkippedNum   int64
+	ErrorNum     int64
+	SkippedPaths []string
+	Errors       []error
+}
+
+// Unpack extracts the zip archive at src into destDir, reversing Pack. It is a thin wrapper
+// around UnpackReader that opens src itself. It only supports zip archives: tar, tar.gz and
+// tar.zst archives written by PackArchive cannot currently be unpacked by this library.
+func Unpack(src string, destDir string, opts UnpackOptions) (*UnpackResult, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return UnpackReader(f, info.Size(), destDir, opts)
+}
+
+// UnpackReader extracts a zip archive read from r (of the given size) into destDir. Entries
+// are streamed one at a time via archive/zip; file modes and modification times are restored
+// from the archive headers. Any entry whose cleaned path would escape destDir (Zip-Slip) is
+// rejected and counted as skipped rather than extracted.
+func UnpackReader(r io.ReaderAt, size int64, destDir string, opts UnpackOptions) (*UnpackResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	destDir, err = filepath.Abs(destDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, err
+	}
+
+	result := new(UnpackResult)
+
+	manifest, err := decodeManifest(zr.File)
+	if err != nil {
+		return nil, fmt.Errorf("decoding embedded manifest: %w", err)
+	}
+
+	var priorReader *zip.ReadCloser
+	var priorManifest Manifest
+	if opts.PriorArchive != "" {
+		priorReader, err = zip.OpenReader(opts.PriorArchive)
+		if err != nil {
+			return nil, fmt.Errorf("opening prior archive: %w", err)
+		}
+		defer priorReader.Close()
+
+		priorManifest, err = decodeManifest(priorReader.File)
+		if err != nil {
+			return nil, fmt.Errorf("decoding prior archive's embedded manifest: %w", err)
+		}
+	}
+
+	if opts.Consumer != nil {
+		var total int64
+		for _, entry := range zr.File {
+			total += int64(entry.UncompressedSize64)
+		}
+		opts.Consumer.OnScanProgress(int64(len(zr.File)), total)
+	}
+
+	for _, entry := range zr.File {
+		if entry.Name == manifestEntryName {
+			continue
+		}
+
+		name := entry.Name
+		if opts.TargetBaseDir != "" {
+			name = strings.TrimPrefix(name, opts.TargetBaseDir+"/")
+		}
+
+		size := int64(entry.UncompressedSize64)
+		if opts.Consumer != nil {
+			opts.Consumer.OnFileStart(entry.Name, size)
+		}
+
+		target, ok := safeJoin(destDir, name)
+		if !ok {
+			result.SkippedNum += 1
+			result.SkippedPaths = append(result.SkippedPaths, entry.Name)
+			err := fmt.Errorf("unsafe path escapes destination: %s", entry.Name)
+			if opts.Progress != nil {
+				opts.Progress(entry.Name, size, err)
+			}
+			if opts.Consumer != nil {
+				opts.Consumer.OnFileDone(entry.Name, err)
+			}
+			continue
+		}
+
+		var err error
+		if m, ok := manifest[entry.Name]; ok && m.Placeholder {
+			dedupOf := m.DedupOf
+			if dedupOf == "" {
+				dedupOf = entry.Name
+			}
+			err = extractPlaceholder(zr, manifest, entry, dedupOf, priorReader, priorManifest, target, opts)
+		} else {
+			err = extractEntry(entry, target, opts)
+		}
+		if err != nil {
+			result.ErrorNum += 1
+			result.Errors = append(result.Errors, err)
+		} else {
+			result.ExtractedNum += 1
+		}
+		if opts.Progress != nil {
+			opts.Progress(entry.Name, size, err)
+		}
+		if opts.Consumer != nil {
+			opts.Consumer.OnBytes(size)
+			opts.Consumer.OnFileDone(entry.Name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// extractPlaceholder restores a deduplicated zero-byte entry by copying the content of the
+// archive entry named dedupOf - the file that actually holds the bytes, which PackArchive may
+// have deduplicated against a file at a different path, whether earlier in the same snapshot
+// or in the prior one. It is looked up first among zr's own entries (intra-snapshot dedup),
+// then, if not found there, among priorReader's (inter-snapshot dedup). A candidate is only
+// used if it is not itself a placeholder: the common case of a file unchanged at the same path
+// across snapshots gives dedupOf the file's own current-archive name, so the first candidate
+// found there would otherwise be the placeholder being resolved, not its content. A candidate
+// must also carry the same manifest SHA256 as entry, so a path whose content changed since the
+// prior snapshot is not taken as the source (a prior-archive candidate without a manifest
+// hash is accepted). If no entry
+// with real content is found in either archive, it falls back to extracting entry as-is (i.e.
+// writing an empty file).
+func extractPlaceholder(zr *zip.Reader, manifest Manifest, entry *zip.File, dedupOf string, priorReader *zip.ReadCloser, priorManifest Manifest, target string, opts UnpackOptions) error {
+	want := manifest[entry.Name].SHA256
+	for _, f := range zr.File {
+		if f.Name == dedupOf && f.Name != manifestEntryName && !manifest[f.Name].Placeholder && manifest[f.Name].SHA256 == want {
+			return extractEntry(f, target, opts)
+		}
+	}
+	if priorReader != nil {
+		for _, f := range priorReader.File {
+			p := priorManifest[f.Name]
+			if f.Name == dedupOf && !p.Placeholder && (p.SHA256 == "" || p.SHA256 == want) {
+				return extractEntry(f, target, opts)
+			}
+		}
+	}
+	return extractEntry(entry, target, opts)
+}
+
+// safeJoin joins destDir and name, returning the cleaned absolute path and false if that path
+// would not be contained within destDir (Zip-Slip protection).
+func safeJoin(destDir string, name string) (string, bool) {
+	target := filepath.Join(destDir, name)
+	if target != destDir && !strings.HasPrefix(target, destDir+string(os.PathSeparator)) {
+		return "", false
+	}
+	return target, true
+}
+
+// extractEntry extracts a single zip entry to target, creating parent directories as needed
+// and restoring the entry's file mode and modification time.
+func extractEntry(entry *zip.File, target string, opts UnpackOptions) error {
+	mode := entry.Mode()
+
+	if mode.IsDir() {
+		return os.MkdirAll(target, mode.Perm())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if !opts.Overwrite {
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("%s already exists", target)
+		}
+	}
+
+	src, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, src); err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, entry.Modified, entry.Modified)
+}
@@ -0,0 +1,147 @@
+package packdir
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRawZip creates a zip file at path whose entries are exactly the given name/content
+// pairs, bypassing Pack/PackArchive so entry names can be crafted directly (e.g. for Zip-Slip
+// path-traversal entries that zipEntryName would never itself produce).
+func writeRawZip(t *testing.T, path string, entries map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range entries {
+		out, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := out.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestUnpackRejectsZipSlip verifies that an entry whose name would extract outside destDir is
+// rejected rather than written, and counted as skipped rather than extracted.
+func TestUnpackRejectsZipSlip(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "evil.zip")
+	writeRawZip(t, archive, map[string]string{
+		"../escaped.txt": "should never be written",
+		"safe.txt":       "this one is fine",
+	})
+
+	destDir := filepath.Join(root, "dest")
+	result, err := Unpack(archive, destDir, UnpackOptions{})
+	if err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+
+	if result.SkippedNum != 1 || len(result.SkippedPaths) != 1 || result.SkippedPaths[0] != "../escaped.txt" {
+		t.Errorf("expected ../escaped.txt to be the sole skipped entry, got SkippedNum=%d SkippedPaths=%v", result.SkippedNum, result.SkippedPaths)
+	}
+	if result.ExtractedNum != 1 {
+		t.Errorf("expected safe.txt to still be extracted, got ExtractedNum=%d", result.ExtractedNum)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "escaped.txt")); err == nil {
+		t.Error("../escaped.txt was written outside destDir")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "safe.txt")); err != nil {
+		t.Errorf("safe.txt was not extracted: %v", err)
+	}
+}
+
+// TestUnpackOverwrite verifies that an existing file blocks extraction when Overwrite is false,
+// and is replaced when Overwrite is true.
+func TestUnpackOverwrite(t *testing.T) {
+	root := t.TempDir()
+	archiveV1 := filepath.Join(root, "v1.zip")
+	writeRawZip(t, archiveV1, map[string]string{"a.txt": "version one"})
+	archiveV2 := filepath.Join(root, "v2.zip")
+	writeRawZip(t, archiveV2, map[string]string{"a.txt": "version two"})
+
+	destDir := filepath.Join(root, "dest")
+	if _, err := Unpack(archiveV1, destDir, UnpackOptions{}); err != nil {
+		t.Fatalf("first Unpack: %v", err)
+	}
+
+	result, err := Unpack(archiveV2, destDir, UnpackOptions{})
+	if err != nil {
+		t.Fatalf("second Unpack: %v", err)
+	}
+	if result.ErrorNum != 1 {
+		t.Errorf("expected re-unpacking without Overwrite to error on the existing file, got ErrorNum=%d", result.ErrorNum)
+	}
+	got, err := os.ReadFile(filepath.Join(destDir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "version one" {
+		t.Errorf("a.txt changed to %q without Overwrite, want it left as \"version one\"", got)
+	}
+
+	result, err = Unpack(archiveV2, destDir, UnpackOptions{Overwrite: true})
+	if err != nil {
+		t.Fatalf("third Unpack: %v", err)
+	}
+	if result.ErrorNum != 0 || result.ExtractedNum != 1 {
+		t.Errorf("expected Overwrite: true to replace a.txt cleanly, got ErrorNum=%d ExtractedNum=%d", result.ErrorNum, result.ExtractedNum)
+	}
+	got, err = os.ReadFile(filepath.Join(destDir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "version two" {
+		t.Errorf("a.txt = %q after Overwrite, want \"version two\"", got)
+	}
+}
+
+// TestUnpackProgressCallback verifies that Progress is called for every archive entry, with
+// the entry's name, size and a nil error on success.
+func TestUnpackProgressCallback(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "snap.zip")
+	writeRawZip(t, archive, map[string]string{
+		"a.txt": "hello",
+		"b.txt": "world!",
+	})
+
+	seen := make(map[string]int64)
+	destDir := filepath.Join(root, "dest")
+	opts := UnpackOptions{
+		Progress: func(name string, size int64, err error) {
+			if err != nil {
+				t.Errorf("unexpected error for %s: %v", name, err)
+			}
+			seen[name] = size
+		},
+	}
+	if _, err := Unpack(archive, destDir, opts); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+
+	want := map[string]int64{"a.txt": 5, "b.txt": 6}
+	for name, size := range want {
+		got, ok := seen[name]
+		if !ok {
+			t.Errorf("Progress was never called for %s", name)
+			continue
+		}
+		if got != size {
+			t.Errorf("Progress reported size %d for %s, want %d", got, name, size)
+		}
+	}
+}